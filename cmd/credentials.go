@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	ecsdeploy "github.com/crowdworks/ecs-goploy/deploy"
+	"github.com/spf13/pflag"
+)
+
+// credentialFlags holds the AWS credential related flags shared by the
+// deploy, recreate and task commands.
+type credentialFlags struct {
+	profile string
+	region  string
+
+	assumeRoleARN string
+	externalID    string
+	mfaSerial     string
+	sessionName   string
+}
+
+// addCredentialFlags registers --profile, --region and the --assume-role-*
+// flags onto flags.
+func (c *credentialFlags) addCredentialFlags(flags *pflag.FlagSet) {
+	flags.StringVarP(&c.profile, "profile", "p", "", "AWS Profile to use")
+	flags.StringVarP(&c.region, "region", "r", "", "AWS Region Name")
+	flags.StringVar(&c.assumeRoleARN, "assume-role-arn", "", "ARN of an IAM role to assume before calling AWS, for cross-account deploys")
+	flags.StringVar(&c.externalID, "external-id", "", "External ID to pass to sts:AssumeRole")
+	flags.StringVar(&c.mfaSerial, "mfa-serial", "", "Serial number of the MFA device to use when assuming the role, prompts for a token code on stdin")
+	flags.StringVar(&c.sessionName, "session-name", "", "Session name for the assumed role, defaults to ecs-goploy")
+}
+
+// options builds the CredentialOptions passed to the deploy package's constructors.
+func (c *credentialFlags) options() ecsdeploy.CredentialOptions {
+	return ecsdeploy.CredentialOptions{
+		Profile:       c.profile,
+		Region:        c.region,
+		AssumeRoleARN: c.assumeRoleARN,
+		ExternalID:    c.externalID,
+		MFASerial:     c.mfaSerial,
+		SessionName:   c.sessionName,
+	}
+}
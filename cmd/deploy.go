@@ -9,13 +9,22 @@ import (
 )
 
 type deploy struct {
+	credentialFlags
+	outputFlags
+	pollFlags
+
 	cluster        string
 	name           string
 	imageWithTag   string
-	profile        string
-	region         string
 	timeout        int
 	enableRollback bool
+
+	strategy          string
+	canaryWeight      int64
+	bakeTime          int
+	minHealthyPercent int64
+	max5xxRate        float64
+	targetGroupARN    string
 }
 
 func deployCmd() *cobra.Command {
@@ -30,18 +39,67 @@ func deployCmd() *cobra.Command {
 	flags.StringVarP(&d.cluster, "cluster", "c", "", "Name of ECS cluster")
 	flags.StringVarP(&d.name, "service-name", "n", "", "Name of service to deploy")
 	flags.StringVarP(&d.imageWithTag, "image", "i", "", "Name of Docker image to run, ex: repo/image:latest")
-	flags.StringVarP(&d.profile, "profile", "p", "", "AWS Profile to use")
-	flags.StringVarP(&d.region, "region", "r", "", "AWS Region Name")
 	flags.IntVarP(&d.timeout, "timeout", "t", 300, "Timeout seconds. Script monitors ECS Service for new task definition to be running")
 	flags.BoolVar(&d.enableRollback, "enable-rollback", false, "Rollback task definition if new version is not running before TIMEOUT")
+	flags.StringVar(&d.strategy, "strategy", "rolling", "Rollout strategy to use: rolling, canary or bluegreen")
+	flags.Int64Var(&d.canaryWeight, "canary-weight", 10, "Percentage of tasks to shift to the canary before promoting (canary strategy only)")
+	flags.IntVar(&d.bakeTime, "bake-time", 300, "Seconds to observe the canary before promoting it (canary/bluegreen strategies)")
+	flags.Int64Var(&d.minHealthyPercent, "canary-min-healthy-percent", 100, "Minimum percentage of canary tasks that must be running during the bake window")
+	flags.Float64Var(&d.max5xxRate, "canary-max-5xx-rate", 0, "Abort the rollout if the target group's 5xx rate (percent) exceeds this value during the bake window, 0 to disable")
+	flags.StringVar(&d.targetGroupARN, "target-group-arn", "", "ALB target group ARN the canary service is registered with, required for --canary-max-5xx-rate")
+	d.addCredentialFlags(flags)
+	d.addOutputFlag(flags)
+	d.addPollFlags(flags)
 
 	return cmd
 }
 
 func (d *deploy) deploy(cmd *cobra.Command, args []string) {
-	e := ecsdeploy.NewDeploy(d.cluster, d.name, d.profile, d.region, d.imageWithTag, (time.Duration(d.timeout) * time.Second), d.enableRollback)
+	switch ecsdeploy.Strategy(d.strategy) {
+	case ecsdeploy.StrategyRolling:
+		d.rollingDeploy()
+	case ecsdeploy.StrategyCanary, ecsdeploy.StrategyBlueGreen:
+		d.canaryDeploy()
+	default:
+		log.Fatalf("[ERROR] unsupported --strategy %q, must be one of: rolling, canary, bluegreen", d.strategy)
+	}
+}
+
+func (d *deploy) rollingDeploy() {
+	e := ecsdeploy.NewDeploy(d.cluster, d.name, d.imageWithTag, d.credentialFlags.options(), (time.Duration(d.timeout) * time.Second), d.enableRollback)
 	if err := e.Deploy(); err != nil {
 		log.Fatalf("[ERROR] %v", err)
 	}
 	log.Println("[INFO] Deploy success")
-}
\ No newline at end of file
+}
+
+func (d *deploy) canaryDeploy() {
+	opts := ecsdeploy.CanaryOptions{
+		Strategy:          ecsdeploy.Strategy(d.strategy),
+		Weight:            d.canaryWeight,
+		BakeTime:          time.Duration(d.bakeTime) * time.Second,
+		MinHealthyPercent: d.minHealthyPercent,
+		Max5xxRate:        d.max5xxRate,
+	}
+	if len(d.targetGroupARN) > 0 {
+		opts.TargetGroupARN = &d.targetGroupARN
+	}
+
+	e, err := ecsdeploy.NewCanaryDeploy(d.cluster, d.name, d.imageWithTag, d.credentialFlags.options(), (time.Duration(d.timeout) * time.Second), opts, d.pollFlags.options())
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		drainEvents(d.output, e.Events)
+		close(done)
+	}()
+
+	err = e.Deploy()
+	<-done
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	log.Println("[INFO] Deploy success")
+}
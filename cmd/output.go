@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	ecsdeploy "github.com/crowdworks/ecs-goploy/deploy"
+	"github.com/spf13/pflag"
+)
+
+// outputFlags holds the --output flag shared by the deploy, recreate and task commands.
+type outputFlags struct {
+	output string
+}
+
+// addOutputFlag registers --output onto flags.
+func (o *outputFlags) addOutputFlag(flags *pflag.FlagSet) {
+	flags.StringVar(&o.output, "output", "text", "Output format: text (pretty-print to the console) or json (stream NDJSON events to stdout)")
+}
+
+// eventJSON is the NDJSON shape streamed to stdout in --output json mode.
+type eventJSON struct {
+	Phase     string `json:"phase"`
+	Message   string `json:"message"`
+	TaskARN   string `json:"task_arn,omitempty"`
+	Timestamp string `json:"timestamp"`
+	ExitCode  *int64 `json:"exit_code,omitempty"`
+}
+
+// drainEvents prints every Event received on events, either as a pretty
+// console line or as an NDJSON line on stdout, until events is closed. It
+// runs to completion synchronously, so callers watching a blocking Deploy/
+// Recreate/RunTasks call should run it in its own goroutine.
+func drainEvents(output string, events <-chan ecsdeploy.Event) {
+	for ev := range events {
+		if output == "json" {
+			printEventJSON(ev)
+		} else {
+			printEventText(ev)
+		}
+	}
+}
+
+func printEventText(ev ecsdeploy.Event) {
+	log.Printf("[INFO] [%s] %s\n", ev.Phase, ev.Message)
+}
+
+func printEventJSON(ev ecsdeploy.Event) {
+	j := eventJSON{
+		Phase:     string(ev.Phase),
+		Message:   ev.Message,
+		Timestamp: ev.Timestamp.Format(time.RFC3339Nano),
+		ExitCode:  ev.ExitCode,
+	}
+	if ev.TaskARN != nil {
+		j.TaskARN = *ev.TaskARN
+	}
+	enc := json.NewEncoder(os.Stdout)
+	if err := enc.Encode(j); err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] failed to encode event: %v\n", err)
+	}
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"time"
+
+	ecsdeploy "github.com/crowdworks/ecs-goploy/deploy"
+	"github.com/spf13/pflag"
+)
+
+// pollFlags holds the --poll-* flags shared by the deploy, recreate and
+// task commands that control how aggressively they poll ECS while waiting
+// for a task or service to stop or reach steady state.
+type pollFlags struct {
+	pollInitial    time.Duration
+	pollMax        time.Duration
+	pollMultiplier float64
+}
+
+// addPollFlags registers --poll-initial, --poll-max and --poll-multiplier
+// onto flags.
+func (p *pollFlags) addPollFlags(flags *pflag.FlagSet) {
+	flags.DurationVar(&p.pollInitial, "poll-initial", ecsdeploy.DefaultWaiterOptions.Initial, "Initial delay between polls while waiting")
+	flags.DurationVar(&p.pollMax, "poll-max", ecsdeploy.DefaultWaiterOptions.Max, "Maximum delay between polls while waiting")
+	flags.Float64Var(&p.pollMultiplier, "poll-multiplier", ecsdeploy.DefaultWaiterOptions.Multiplier, "Factor the poll delay grows by after each round that isn't done yet, and again when ECS throttles the request")
+}
+
+// options builds the WaiterOptions passed to the deploy package's constructors.
+func (p *pollFlags) options() ecsdeploy.WaiterOptions {
+	return ecsdeploy.WaiterOptions{
+		Initial:    p.pollInitial,
+		Max:        p.pollMax,
+		Multiplier: p.pollMultiplier,
+	}
+}
@@ -0,0 +1,148 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	ecsdeploy "github.com/crowdworks/ecs-goploy/deploy"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type recreate struct {
+	credentialFlags
+	outputFlags
+	pollFlags
+
+	cluster      string
+	name         string
+	imageWithTag string
+	timeout      int
+	yes          bool
+
+	launchType                 string
+	subnets                    []string
+	securityGroups             []string
+	assignPublicIP             bool
+	serviceRegistryARN         string
+	capacityProviderStrategies []string
+}
+
+func recreateCmd() *cobra.Command {
+	r := &recreate{}
+	cmd := &cobra.Command{
+		Use:   "recreate",
+		Short: "Recreate ECS service for breaking task definition changes",
+		Run:   r.recreate,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&r.cluster, "cluster", "c", "", "Name of ECS cluster")
+	flags.StringVarP(&r.name, "service-name", "n", "", "Name of service to recreate")
+	flags.StringVarP(&r.imageWithTag, "image", "i", "", "Name of Docker image to run, ex: repo/image:latest")
+	flags.IntVarP(&r.timeout, "timeout", "t", 300, "Timeout seconds. Script monitors the temporary service for new task definition to be running")
+	flags.BoolVar(&r.yes, "yes", false, "Skip the interactive confirmation prompt")
+	flags.StringVar(&r.launchType, "launch-type", "", "Override the launch type (EC2, FARGATE or EXTERNAL), defaults to the existing service's launch type")
+	flags.StringArrayVar(&r.subnets, "subnet", nil, "Override the awsvpc subnet, repeatable. Replaces the existing service's network configuration")
+	flags.StringArrayVar(&r.securityGroups, "security-group", nil, "Override the awsvpc security group, repeatable. Replaces the existing service's network configuration")
+	flags.BoolVar(&r.assignPublicIP, "assign-public-ip", false, "Assign a public IP, only applied when --subnet or --security-group overrides the network configuration")
+	flags.StringVar(&r.serviceRegistryARN, "service-registry-arn", "", "Override the Cloud Map/ServiceConnect registry ARN, defaults to the existing service's registries")
+	flags.StringArrayVar(&r.capacityProviderStrategies, "capacity-provider-strategy", nil, `Override the capacity provider strategy, repeatable. Format: name=<provider>,weight=<n>,base=<n>`)
+	r.addCredentialFlags(flags)
+	r.addOutputFlag(flags)
+	r.addPollFlags(flags)
+
+	return cmd
+}
+
+// parseCapacityProviderStrategy parses a single
+// `--capacity-provider-strategy name=<provider>,weight=<n>,base=<n>` entry.
+func parseCapacityProviderStrategy(spec string) (*ecsdeploy.CapacityProviderStrategyItem, error) {
+	item := &ecsdeploy.CapacityProviderStrategyItem{}
+	for _, field := range strings.Split(spec, ",") {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid --capacity-provider-strategy field %q, expected key=value", field)
+		}
+		key, value := kv[0], kv[1]
+		switch key {
+		case "name":
+			item.CapacityProvider = value
+		case "weight":
+			weight, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("invalid --capacity-provider-strategy weight %q: %v", value, err)
+			}
+			item.Weight = weight
+		case "base":
+			base, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("invalid --capacity-provider-strategy base %q: %v", value, err)
+			}
+			item.Base = base
+		default:
+			return nil, errors.Errorf("unknown --capacity-provider-strategy key %q", key)
+		}
+	}
+	if len(item.CapacityProvider) == 0 {
+		return nil, errors.New("--capacity-provider-strategy requires a name=<provider> field")
+	}
+	return item, nil
+}
+
+func (r *recreate) recreate(cmd *cobra.Command, args []string) {
+	if !r.yes && !confirm(fmt.Sprintf("This will replace service %q in cluster %q with a new one. Continue?", r.name, r.cluster)) {
+		log.Println("[INFO] Aborted")
+		return
+	}
+
+	opts := ecsdeploy.RecreateOptions{
+		LaunchType:         r.launchType,
+		Subnets:            r.subnets,
+		SecurityGroups:     r.securityGroups,
+		AssignPublicIP:     r.assignPublicIP,
+		ServiceRegistryARN: r.serviceRegistryARN,
+	}
+	for _, raw := range r.capacityProviderStrategies {
+		item, err := parseCapacityProviderStrategy(raw)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		opts.CapacityProviderStrategy = append(opts.CapacityProviderStrategy, *item)
+	}
+
+	e, err := ecsdeploy.NewRecreate(r.cluster, r.name, r.imageWithTag, r.credentialFlags.options(), (time.Duration(r.timeout) * time.Second), opts, r.pollFlags.options())
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		drainEvents(r.output, e.Events)
+		close(done)
+	}()
+
+	err = e.Recreate()
+	<-done
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+	log.Println("[INFO] Recreate success")
+}
+
+// confirm prompts the user with a yes/no question on stdin and returns
+// whether they answered yes.
+func confirm(message string) bool {
+	fmt.Printf("%s [y/N]: ", message)
+	scanner := bufio.NewScanner(os.Stdin)
+	if !scanner.Scan() {
+		return false
+	}
+	answer := strings.ToLower(strings.TrimSpace(scanner.Text()))
+	return answer == "y" || answer == "yes"
+}
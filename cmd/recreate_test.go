@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	ecsdeploy "github.com/crowdworks/ecs-goploy/deploy"
+)
+
+func TestParseCapacityProviderStrategy(t *testing.T) {
+	cases := []struct {
+		name    string
+		spec    string
+		want    *ecsdeploy.CapacityProviderStrategyItem
+		wantErr bool
+	}{
+		{
+			name: "all fields",
+			spec: "name=FARGATE_SPOT,weight=1,base=2",
+			want: &ecsdeploy.CapacityProviderStrategyItem{CapacityProvider: "FARGATE_SPOT", Weight: 1, Base: 2},
+		},
+		{
+			name: "name only",
+			spec: "name=FARGATE",
+			want: &ecsdeploy.CapacityProviderStrategyItem{CapacityProvider: "FARGATE"},
+		},
+		{
+			name:    "missing name",
+			spec:    "weight=1,base=2",
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric weight",
+			spec:    "name=FARGATE,weight=abc",
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric base",
+			spec:    "name=FARGATE,base=abc",
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			spec:    "name=FARGATE,bogus=1",
+			wantErr: true,
+		},
+		{
+			name:    "field without equals",
+			spec:    "name",
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseCapacityProviderStrategy(c.spec)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseCapacityProviderStrategy(%q) = %+v, nil, want error", c.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseCapacityProviderStrategy(%q) returned error: %v", c.spec, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("parseCapacityProviderStrategy(%q) = %+v, want %+v", c.spec, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	ecsdeploy "github.com/crowdworks/ecs-goploy/deploy"
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+type task struct {
+	credentialFlags
+	outputFlags
+	pollFlags
+
+	cluster  string
+	oneOffs  []string
+	timeout  int
+	parallel bool
+}
+
+func taskCmd() *cobra.Command {
+	t := &task{}
+	cmd := &cobra.Command{
+		Use:   "task",
+		Short: "Run one-off ECS tasks",
+		Run:   t.task,
+	}
+
+	flags := cmd.Flags()
+	flags.StringVarP(&t.cluster, "cluster", "c", "", "Name of ECS cluster")
+	flags.StringArrayVar(&t.oneOffs, "one-off", nil, `One-off task to run, repeatable. Format: name=<container>,cmd="<shell>",base=<task definition>`)
+	flags.IntVarP(&t.timeout, "timeout", "t", 300, "Timeout seconds. Script monitors ECS task for new task definition to be running")
+	flags.BoolVar(&t.parallel, "parallel", false, "Run the one-off tasks concurrently instead of sequentially")
+	t.addCredentialFlags(flags)
+	t.addOutputFlag(flags)
+	t.addPollFlags(flags)
+
+	return cmd
+}
+
+// oneOffSpec is a single `--one-off name=<container>,cmd="<shell>",base=<td>` entry.
+type oneOffSpec struct {
+	name    string
+	command string
+	base    string
+}
+
+// parseOneOff parses a single --one-off flag value into its name, cmd and
+// base components. Values may be quoted to allow commas and spaces, e.g.
+// `name=web,cmd="rails db:migrate",base=myapp-web`.
+func parseOneOff(spec string) (*oneOffSpec, error) {
+	fields, err := splitOneOffFields(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	o := &oneOffSpec{}
+	for _, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.Errorf("invalid --one-off field %q, expected key=value", field)
+		}
+		key, value := kv[0], strings.Trim(kv[1], `"`)
+		switch key {
+		case "name":
+			o.name = value
+		case "cmd":
+			o.command = value
+		case "base":
+			o.base = value
+		default:
+			return nil, errors.Errorf("unknown --one-off key %q", key)
+		}
+	}
+
+	if len(o.name) == 0 {
+		return nil, errors.New("--one-off requires a name=<container> field")
+	}
+	if len(o.base) == 0 {
+		return nil, errors.New("--one-off requires a base=<task definition> field")
+	}
+	return o, nil
+}
+
+// splitOneOffFields splits a comma-separated --one-off value into its
+// key=value fields, treating commas inside double quotes as literal so a
+// cmd="..." field can contain commas.
+func splitOneOffFields(spec string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range spec {
+		switch r {
+		case '"':
+			inQuotes = !inQuotes
+			current.WriteRune(r)
+		case ',':
+			if inQuotes {
+				current.WriteRune(r)
+				continue
+			}
+			fields = append(fields, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, errors.Errorf("unterminated quote in --one-off value %q", spec)
+	}
+	fields = append(fields, current.String())
+	return fields, nil
+}
+
+func (t *task) task(cmd *cobra.Command, args []string) {
+	if len(t.oneOffs) == 0 {
+		log.Fatal("[ERROR] at least one --one-off flag is required")
+	}
+
+	tasks := make([]*ecsdeploy.Task, 0, len(t.oneOffs))
+	for _, raw := range t.oneOffs {
+		spec, err := parseOneOff(raw)
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		base := spec.base
+		newTask, err := ecsdeploy.NewTask(t.cluster, spec.name, "", spec.command, &base, (time.Duration(t.timeout) * time.Second), t.credentialFlags.options(), t.pollFlags.options())
+		if err != nil {
+			log.Fatalf("[ERROR] %v", err)
+		}
+		tasks = append(tasks, newTask)
+	}
+
+	var wg sync.WaitGroup
+	for _, task := range tasks {
+		wg.Add(1)
+		go func(events <-chan ecsdeploy.Event) {
+			defer wg.Done()
+			drainEvents(t.output, events)
+		}(task.Events)
+	}
+
+	results, err := ecsdeploy.RunTasks(tasks, t.parallel)
+	wg.Wait()
+	if err != nil {
+		log.Fatalf("[ERROR] %v", err)
+	}
+
+	if t.output == "json" {
+		printTaskResultsJSON(results)
+	} else {
+		printTaskResultsText(results)
+	}
+
+	if ecsdeploy.AnyFailed(results) {
+		os.Exit(1)
+	}
+}
+
+func printTaskResultsText(results []ecsdeploy.OneOffResult) {
+	for _, r := range results {
+		if r.Err != nil {
+			log.Printf("[ERROR] %s (%s): %v\n", r.Name, r.TaskARN, r.Err)
+			continue
+		}
+		log.Printf("[INFO] %s (%s) exited %d in %s\n", r.Name, r.TaskARN, r.ExitCode, r.Duration)
+	}
+}
+
+type taskResultJSON struct {
+	Name     string `json:"name"`
+	TaskARN  string `json:"task_arn"`
+	ExitCode int64  `json:"exit_code"`
+	Duration string `json:"duration"`
+	Error    string `json:"error,omitempty"`
+}
+
+func printTaskResultsJSON(results []ecsdeploy.OneOffResult) {
+	out := make([]taskResultJSON, 0, len(results))
+	for _, r := range results {
+		j := taskResultJSON{
+			Name:     r.Name,
+			TaskARN:  r.TaskARN,
+			ExitCode: r.ExitCode,
+			Duration: r.Duration.String(),
+		}
+		if r.Err != nil {
+			j.Error = r.Err.Error()
+		}
+		out = append(out, j)
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(out); err != nil {
+		fmt.Fprintf(os.Stderr, "[ERROR] failed to encode results: %v\n", err)
+	}
+}
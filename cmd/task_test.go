@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitOneOffFields(t *testing.T) {
+	cases := []struct {
+		name    string
+		spec    string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "simple fields",
+			spec: "name=web,base=myapp-web",
+			want: []string{"name=web", "base=myapp-web"},
+		},
+		{
+			name: "commas inside quotes are literal",
+			spec: `name=web,cmd="rails db:migrate, now",base=myapp-web`,
+			want: []string{"name=web", `cmd="rails db:migrate, now"`, "base=myapp-web"},
+		},
+		{
+			name:    "unterminated quote",
+			spec:    `name=web,cmd="rails db:migrate`,
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := splitOneOffFields(c.spec)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("splitOneOffFields(%q) = %v, nil, want error", c.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("splitOneOffFields(%q) returned error: %v", c.spec, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("splitOneOffFields(%q) = %v, want %v", c.spec, got, c.want)
+			}
+		})
+	}
+}
+
+func TestParseOneOff(t *testing.T) {
+	cases := []struct {
+		name    string
+		spec    string
+		want    *oneOffSpec
+		wantErr bool
+	}{
+		{
+			name: "all fields",
+			spec: `name=web,cmd="rails db:migrate",base=myapp-web`,
+			want: &oneOffSpec{name: "web", command: "rails db:migrate", base: "myapp-web"},
+		},
+		{
+			name:    "missing name",
+			spec:    "base=myapp-web",
+			wantErr: true,
+		},
+		{
+			name:    "missing base",
+			spec:    "name=web",
+			wantErr: true,
+		},
+		{
+			name:    "unknown key",
+			spec:    "name=web,base=myapp-web,bogus=1",
+			wantErr: true,
+		},
+		{
+			name:    "field without equals",
+			spec:    "name",
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, err := parseOneOff(c.spec)
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("parseOneOff(%q) = %+v, nil, want error", c.spec, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseOneOff(%q) returned error: %v", c.spec, err)
+			}
+			if !reflect.DeepEqual(got, c.want) {
+				t.Errorf("parseOneOff(%q) = %+v, want %+v", c.spec, got, c.want)
+			}
+		})
+	}
+}
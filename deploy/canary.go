@@ -0,0 +1,438 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/aws/aws-sdk-go/service/elbv2"
+	"github.com/aws/aws-sdk-go/service/elbv2/elbv2iface"
+	"github.com/pkg/errors"
+)
+
+// Strategy is the traffic-shifting strategy used to move a service from its
+// current task definition to a new one.
+type Strategy string
+
+const (
+	// StrategyRolling is the default in-place update performed by Deploy.Deploy.
+	StrategyRolling Strategy = "rolling"
+
+	// StrategyCanary shifts a configurable percentage of tasks to the new
+	// task definition, bakes for a while, then promotes or rolls back.
+	StrategyCanary Strategy = "canary"
+
+	// StrategyBlueGreen stands up a parallel service running only the new
+	// task definition and cuts traffic over once it is healthy.
+	StrategyBlueGreen Strategy = "bluegreen"
+)
+
+// canaryServiceSuffix names the temporary service created to run the
+// canary/blue-green task definition alongside the production service.
+const canaryServiceSuffix = "-canary"
+
+// CanaryOptions configures a canary or blue-green rollout.
+type CanaryOptions struct {
+	// Strategy selects between canary and blue-green traffic shifting.
+	Strategy Strategy
+
+	// Weight is the percentage of the service's desired count (1-99) to
+	// run on the canary during the bake window. Ignored for
+	// StrategyBlueGreen, which always runs the canary at 100%.
+	Weight int64
+
+	// BakeTime is how long to observe the canary before promoting it.
+	BakeTime time.Duration
+
+	// MinHealthyPercent aborts the rollout if fewer than this percentage
+	// of canary tasks are RUNNING at the end of the bake window.
+	MinHealthyPercent int64
+
+	// Max5xxRate aborts the rollout if the target group's 5xx rate
+	// (percent) exceeds this value during the bake window. Zero disables
+	// the check.
+	Max5xxRate float64
+
+	// TargetGroupARN is the ALB target group the canary service is
+	// registered with, used for the 5xx rate check.
+	TargetGroupARN *string
+}
+
+// CanaryDeploy registers a new task definition alongside the one a service
+// currently runs, and gradually shifts traffic to it, rolling back
+// automatically if it fails to meet the configured success criteria.
+type CanaryDeploy struct {
+	awsECS   ecsiface.ECSAPI
+	awsCW    cloudwatchiface.CloudWatchAPI
+	awsELBV2 elbv2iface.ELBV2API
+
+	// Cluster is the name of the ECS cluster.
+	Cluster string
+
+	// Name is the name of the service being deployed.
+	Name string
+
+	// TaskDefinition is the base task definition to register a new revision from.
+	TaskDefinition *TaskDefinition
+
+	// NewImage is the image to deploy.
+	NewImage *Image
+
+	// Options controls the rollout behavior.
+	Options CanaryOptions
+
+	// Timeout bounds every step of the rollout except the bake window,
+	// which is bounded separately by Options.BakeTime. The two are
+	// additive: the rollout as a whole may take up to Timeout+BakeTime.
+	Timeout time.Duration
+
+	// Events carries structured progress updates in place of log.Printf
+	// calls. It is closed once Deploy returns.
+	Events chan Event
+
+	// Poll controls the backoff used while waiting for the canary service
+	// to reach steady state.
+	Poll WaiterOptions
+}
+
+// NewCanaryDeploy returns a new CanaryDeploy, initializing the aws ecs and
+// cloudwatch API clients.
+func NewCanaryDeploy(cluster, name, imageWithTag string, creds CredentialOptions, timeout time.Duration, opts CanaryOptions, poll WaiterOptions) (*CanaryDeploy, error) {
+	if opts.Strategy != StrategyCanary && opts.Strategy != StrategyBlueGreen {
+		return nil, errors.Errorf("unsupported strategy: %s", opts.Strategy)
+	}
+	if opts.Strategy == StrategyCanary && (opts.Weight <= 0 || opts.Weight >= 100) {
+		return nil, errors.New("canary weight must be between 1 and 99")
+	}
+	if opts.Max5xxRate > 0 && opts.TargetGroupARN == nil {
+		return nil, errors.New("--target-group-arn is required when --canary-max-5xx-rate is set")
+	}
+
+	config := newConfig(creds)
+	awsSession := session.New()
+	awsECS := ecs.New(awsSession, config)
+	awsCW := cloudwatch.New(awsSession, config)
+	awsELBV2 := elbv2.New(awsSession, config)
+	taskDefinition := NewTaskDefinition(creds.Profile, creds.Region)
+
+	repository, tag, err := divideImageAndTag(imageWithTag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CanaryDeploy{
+		awsECS:         awsECS,
+		awsCW:          awsCW,
+		awsELBV2:       awsELBV2,
+		Cluster:        cluster,
+		Name:           name,
+		TaskDefinition: taskDefinition,
+		NewImage: &Image{
+			*repository,
+			*tag,
+		},
+		Options: opts,
+		Timeout: timeout,
+		Events:  newEvents(),
+		Poll:    poll,
+	}, nil
+}
+
+// Deploy registers a new task definition revision, creates a temporary
+// canary service running it, waits out the bake window while checking the
+// success criteria, then either promotes the canary to 100% or rolls it
+// back and tears it down.
+func (c *CanaryDeploy) Deploy() error {
+	if c.Events != nil {
+		defer close(c.Events)
+	}
+
+	// The bake window is additive to Timeout rather than carved out of
+	// it: Timeout alone already equals the default BakeTime, and nesting
+	// bake's own deadline inside a ctx with an earlier deadline would
+	// make every default invocation time out mid-bake.
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout+c.Options.BakeTime)
+	defer cancel()
+
+	current, err := c.describeService(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to describe current service")
+	}
+
+	publish(c.Events, PhaseRegisteringTaskDef, "Registering new task definition", nil, nil)
+	newTaskDef, err := c.TaskDefinition.Register(c.Name, c.NewImage)
+	if err != nil {
+		return errors.Wrap(err, "failed to register new task definition")
+	}
+
+	canaryName := c.Name + canaryServiceSuffix
+	canaryCount := c.canaryTaskCount(*current.DesiredCount)
+
+	publish(c.Events, PhaseUpdatingService, fmt.Sprintf("Creating canary service %s with %d task(s)", canaryName, canaryCount), nil, nil)
+	if err := c.createCanaryService(ctx, current, canaryName, newTaskDef, canaryCount); err != nil {
+		return errors.Wrap(err, "failed to create canary service")
+	}
+
+	publish(c.Events, PhaseWaitingSteadyState, fmt.Sprintf("Waiting for %s to reach steady state", canaryName), nil, nil)
+	if err := c.waitServiceStable(ctx, canaryName); err != nil {
+		publish(c.Events, PhaseRollbackTriggered, fmt.Sprintf("Tearing down %s", canaryName), nil, nil)
+		c.teardownCanary(canaryName)
+		return errors.Wrap(err, "canary service failed to reach steady state")
+	}
+
+	publish(c.Events, PhaseWaitingSteadyState, fmt.Sprintf("Baking canary for %s", c.Options.BakeTime), nil, nil)
+	if err := c.bake(ctx, canaryName); err != nil {
+		publish(c.Events, PhaseRollbackTriggered, fmt.Sprintf("Tearing down %s", canaryName), nil, nil)
+		c.teardownCanary(canaryName)
+		return errors.Wrap(err, "canary failed success criteria, rolled back")
+	}
+
+	publish(c.Events, PhaseUpdatingService, "Promoting canary to 100%", nil, nil)
+	if err := c.promote(current, newTaskDef.TaskDefinitionArn); err != nil {
+		return errors.Wrap(err, "failed to promote canary")
+	}
+
+	publish(c.Events, PhaseWaitingSteadyState, fmt.Sprintf("Waiting for %s to reach steady state", c.Name), nil, nil)
+	if err := c.waitServiceStable(ctx, c.Name); err != nil {
+		publish(c.Events, PhaseRollbackTriggered, fmt.Sprintf("Reverting %s to its previous task definition", c.Name), nil, nil)
+		if rbErr := c.promote(current, current.TaskDefinition); rbErr != nil {
+			c.teardownCanary(canaryName)
+			return errors.Wrapf(err, "production service failed to reach steady state after promotion, and rollback to %s also failed: %s", *current.TaskDefinition, rbErr)
+		}
+		c.teardownCanary(canaryName)
+		return errors.Wrap(err, "production service failed to reach steady state after promotion, rolled back to its previous task definition")
+	}
+
+	c.teardownCanary(canaryName)
+	return nil
+}
+
+// describeService fetches the current state of the production service.
+func (c *CanaryDeploy) describeService(ctx context.Context) (*ecs.Service, error) {
+	resp, err := c.awsECS.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(c.Cluster),
+		Services: []*string{aws.String(c.Name)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Services) == 0 {
+		return nil, errors.Errorf("service %s not found", c.Name)
+	}
+	return resp.Services[0], nil
+}
+
+// canaryTaskCount computes how many tasks the canary service should run
+// during the bake window for the configured strategy and weight.
+func (c *CanaryDeploy) canaryTaskCount(desiredCount int64) int64 {
+	if c.Options.Strategy == StrategyBlueGreen {
+		return desiredCount
+	}
+	count := desiredCount * c.Options.Weight / 100
+	if count < 1 {
+		count = 1
+	}
+	return count
+}
+
+// createCanaryService creates the temporary service running the new task
+// definition, reusing the production service's network and load balancer
+// configuration.
+func (c *CanaryDeploy) createCanaryService(ctx context.Context, current *ecs.Service, canaryName string, taskDef *ecs.TaskDefinition, count int64) error {
+	_, err := c.awsECS.CreateServiceWithContext(ctx, &ecs.CreateServiceInput{
+		Cluster:              aws.String(c.Cluster),
+		ServiceName:          aws.String(canaryName),
+		TaskDefinition:       taskDef.TaskDefinitionArn,
+		DesiredCount:         aws.Int64(count),
+		LaunchType:           current.LaunchType,
+		NetworkConfiguration: current.NetworkConfiguration,
+		LoadBalancers:        current.LoadBalancers,
+	})
+	return err
+}
+
+// waitServiceStable polls until the named service reaches steady state. See
+// the package-level waitServiceStable for the shared implementation.
+func (c *CanaryDeploy) waitServiceStable(ctx context.Context, name string) error {
+	return waitServiceStable(ctx, NewWaiter(c.Poll), c.awsECS, c.Cluster, name)
+}
+
+// bake observes the canary service until BakeTime elapses, failing fast if
+// it stops being healthy or its target group's 5xx rate crosses Max5xxRate.
+// Polling goes through a Waiter so a transient ThrottlingException from
+// DescribeServices/GetMetricData backs off and retries instead of being
+// treated as a failed bake and triggering a rollback.
+func (c *CanaryDeploy) bake(ctx context.Context, canaryName string) error {
+	var targetGroupDim, loadBalancerDim *string
+	if c.Options.Max5xxRate > 0 && c.Options.TargetGroupARN != nil {
+		var err error
+		targetGroupDim, loadBalancerDim, err = c.targetGroupDimensions(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to resolve target group's load balancer")
+		}
+	}
+
+	deadline := time.Now().Add(c.Options.BakeTime)
+	return NewWaiter(c.Poll).Wait(ctx, func() (bool, error) {
+		resp, err := c.awsECS.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+			Cluster:  aws.String(c.Cluster),
+			Services: []*string{aws.String(canaryName)},
+		})
+		if err != nil {
+			return false, err
+		}
+		if len(resp.Services) == 0 {
+			return false, errors.Errorf("canary service %s disappeared during bake", canaryName)
+		}
+		svc := resp.Services[0]
+		if svc.RunningCount == nil || svc.DesiredCount == nil || *svc.DesiredCount == 0 {
+			return false, errors.New("canary service has no desired tasks")
+		}
+		healthyPercent := *svc.RunningCount * 100 / *svc.DesiredCount
+		if healthyPercent < c.Options.MinHealthyPercent {
+			return false, errors.Errorf("canary healthy percent %d below minimum %d", healthyPercent, c.Options.MinHealthyPercent)
+		}
+		if c.Options.Max5xxRate > 0 && c.Options.TargetGroupARN != nil {
+			rate, err := c.target5xxRate(ctx, targetGroupDim, loadBalancerDim)
+			if err != nil {
+				return false, err
+			}
+			if rate > c.Options.Max5xxRate {
+				return false, errors.Errorf("target group 5xx rate %.2f%% above maximum %.2f%%", rate, c.Options.Max5xxRate)
+			}
+		}
+		return !time.Now().Before(deadline), nil
+	})
+}
+
+// targetGroupDimensions resolves the CloudWatch "TargetGroup" and
+// "LoadBalancer" dimension values for c.Options.TargetGroupARN.
+// HTTPCode_Target_5XX_Count/RequestCount are only published at the
+// combination of both dimensions, and each dimension's value is the
+// resource's ARN suffix rather than its full ARN, so this looks up the
+// target group's load balancer via elbv2 and strips both ARNs down to the
+// form CloudWatch expects.
+func (c *CanaryDeploy) targetGroupDimensions(ctx context.Context) (targetGroup, loadBalancer *string, err error) {
+	resp, err := c.awsELBV2.DescribeTargetGroupsWithContext(ctx, &elbv2.DescribeTargetGroupsInput{
+		TargetGroupArns: []*string{c.Options.TargetGroupARN},
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(resp.TargetGroups) == 0 {
+		return nil, nil, errors.Errorf("target group %s not found", *c.Options.TargetGroupARN)
+	}
+	tg := resp.TargetGroups[0]
+	if len(tg.LoadBalancerArns) == 0 {
+		return nil, nil, errors.Errorf("target group %s is not attached to a load balancer", *c.Options.TargetGroupARN)
+	}
+	return aws.String(targetGroupDimensionValue(*tg.TargetGroupArn)), aws.String(loadBalancerDimensionValue(*tg.LoadBalancerArns[0])), nil
+}
+
+// targetGroupDimensionValue strips a target group ARN down to the
+// "targetgroup/<name>/<id>" label CloudWatch's TargetGroup dimension uses.
+func targetGroupDimensionValue(arn string) string {
+	if i := strings.Index(arn, ":targetgroup/"); i >= 0 {
+		return arn[i+1:]
+	}
+	return arn
+}
+
+// loadBalancerDimensionValue strips a load balancer ARN down to the
+// "app/<name>/<id>" (or "net/<name>/<id>") label CloudWatch's LoadBalancer
+// dimension uses.
+func loadBalancerDimensionValue(arn string) string {
+	if i := strings.Index(arn, ":loadbalancer/"); i >= 0 {
+		return arn[i+len(":loadbalancer/"):]
+	}
+	return arn
+}
+
+// target5xxRate returns the percentage of requests to the canary's target
+// group that received a 5xx response over the last minute, i.e.
+// 100*HTTPCode_Target_5XX_Count/RequestCount. A target group with no
+// requests in the window reports a rate of 0 rather than dividing by zero.
+func (c *CanaryDeploy) target5xxRate(ctx context.Context, targetGroupDim, loadBalancerDim *string) (float64, error) {
+	dimensions := []*cloudwatch.Dimension{
+		{Name: aws.String("TargetGroup"), Value: targetGroupDim},
+		{Name: aws.String("LoadBalancer"), Value: loadBalancerDim},
+	}
+	resp, err := c.awsCW.GetMetricDataWithContext(ctx, &cloudwatch.GetMetricDataInput{
+		StartTime: aws.Time(time.Now().Add(-1 * time.Minute)),
+		EndTime:   aws.Time(time.Now()),
+		MetricDataQueries: []*cloudwatch.MetricDataQuery{
+			{
+				Id: aws.String("errorCount"),
+				MetricStat: &cloudwatch.MetricStat{
+					Metric: &cloudwatch.Metric{
+						Namespace:  aws.String("AWS/ApplicationELB"),
+						MetricName: aws.String("HTTPCode_Target_5XX_Count"),
+						Dimensions: dimensions,
+					},
+					Period: aws.Int64(60),
+					Stat:   aws.String("Sum"),
+				},
+			},
+			{
+				Id: aws.String("requestCount"),
+				MetricStat: &cloudwatch.MetricStat{
+					Metric: &cloudwatch.Metric{
+						Namespace:  aws.String("AWS/ApplicationELB"),
+						MetricName: aws.String("RequestCount"),
+						Dimensions: dimensions,
+					},
+					Period: aws.Int64(60),
+					Stat:   aws.String("Sum"),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var errorCount, requestCount float64
+	for _, result := range resp.MetricDataResults {
+		if len(result.Values) == 0 {
+			continue
+		}
+		switch *result.Id {
+		case "errorCount":
+			errorCount = *result.Values[0]
+		case "requestCount":
+			requestCount = *result.Values[0]
+		}
+	}
+	if requestCount == 0 {
+		return 0, nil
+	}
+	return errorCount / requestCount * 100, nil
+}
+
+// promote points the production service at taskDefArn and scales it back to
+// the original desired count. The caller still has to wait for that rollout
+// to reach steady state. It also doubles as the post-promotion rollback:
+// called with current.TaskDefinition, it reverts production to what it was
+// running before the canary's task definition was promoted.
+func (c *CanaryDeploy) promote(current *ecs.Service, taskDefArn *string) error {
+	_, err := c.awsECS.UpdateService(&ecs.UpdateServiceInput{
+		Cluster:        aws.String(c.Cluster),
+		Service:        aws.String(c.Name),
+		TaskDefinition: taskDefArn,
+		DesiredCount:   current.DesiredCount,
+	})
+	return err
+}
+
+// teardownCanary scales down and removes the temporary canary service. See
+// the package-level teardownService for the shared implementation.
+func (c *CanaryDeploy) teardownCanary(canaryName string) {
+	teardownService(c.awsECS, c.Cluster, canaryName)
+}
@@ -2,34 +2,233 @@ package deploy
 
 import (
 	"os"
+	"sync"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/credentials/ec2rolecreds"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
 	"github.com/aws/aws-sdk-go/aws/defaults"
+	"github.com/aws/aws-sdk-go/aws/ec2metadata"
+	"github.com/aws/aws-sdk-go/aws/session"
 )
 
-// newConfig returns a new aws config
-func newConfig(profile string, region string) *aws.Config {
-	defaultConfig := defaults.Get().Config
-	cred := newCredentials(getenv(profile, "AWS_DEFAULT_PROFILE"), getenv(region, "AWS_DEFAULT_REGION"))
-	return defaultConfig.WithCredentials(cred).WithRegion(getenv(region, "AWS_DEFAULT_REGION"))
+// defaultAssumeRoleSessionName is used when CredentialOptions.SessionName is empty.
+const defaultAssumeRoleSessionName = "ecs-goploy"
+
+// CredentialOptions groups the flags used to build an AWS credential chain:
+// which profile/region to authenticate as, and an optional role to assume
+// on top of it. AssumeRoleARN is usable in cross-account CI pipelines where
+// the runner's own credentials only have permission to assume a deployment
+// role in the target account.
+type CredentialOptions struct {
+	Profile string
+	Region  string
+
+	// AssumeRoleARN, when set, is assumed using the credentials resolved
+	// from Profile/Region as the calling identity.
+	AssumeRoleARN string
+
+	// ExternalID is passed to sts:AssumeRole, required by some cross-account roles.
+	ExternalID string
+
+	// MFASerial, when set, prompts for an MFA token code on stdin when assuming the role.
+	MFASerial string
+
+	// SessionName names the assumed role session. Defaults to "ecs-goploy".
+	SessionName string
+}
+
+// CredentialProvider resolves an aws-sdk-go Credentials from a single
+// source - a shared profile, environment variables, the EC2/ECS instance
+// metadata service, or an assumed role wrapping one of the others.
+type CredentialProvider interface {
+	Credentials() *credentials.Credentials
 }
 
-func newCredentials(profile string, region string) *credentials.Credentials {
-	// temporary config to resolve RemoteCredProvider
-	tmpConfig := defaults.Get().Config.WithRegion(region)
+// SharedProfileProvider resolves credentials from the shared AWS config and
+// credentials files for Profile, including AWS SSO profiles, via the SDK's
+// shared config session state.
+type SharedProfileProvider struct {
+	Profile string
+	Region  string
+}
+
+// Credentials implements CredentialProvider.
+func (p *SharedProfileProvider) Credentials() *credentials.Credentials {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Profile:           p.Profile,
+		Config:            aws.Config{Region: aws.String(p.Region)},
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return credentials.NewCredentials(&errorProvider{err: err})
+	}
+	return sess.Config.Credentials
+}
+
+// EnvProvider resolves credentials from AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY / AWS_SESSION_TOKEN.
+type EnvProvider struct{}
+
+// Credentials implements CredentialProvider.
+func (p *EnvProvider) Credentials() *credentials.Credentials {
+	return credentials.NewEnvCredentials()
+}
+
+// RemoteProvider resolves credentials from the ECS task role, or from the
+// EC2 instance metadata service when not running on ECS. It forces IMDSv2
+// session tokens by disabling the SDK's fallback to token-less IMDSv1 calls.
+type RemoteProvider struct {
+	Region string
+}
+
+// Credentials implements CredentialProvider.
+func (p *RemoteProvider) Credentials() *credentials.Credentials {
+	tmpConfig := defaults.Get().Config.WithRegion(p.Region)
 	tmpHandlers := defaults.Handlers()
 
-	return credentials.NewChainCredentials(
-		[]credentials.Provider{
-			// Read profile before environment variables
-			&credentials.SharedCredentialsProvider{
-				Profile: profile,
-			},
-			&credentials.EnvProvider{},
-			// for IAM Task Role (ECS) and IAM Role
-			defaults.RemoteCredProvider(*tmpConfig, tmpHandlers),
-		})
+	// ECS task/container roles are reached through AWS_CONTAINER_CREDENTIALS_*
+	// and have no IMDS involved; defer to the SDK default in that case.
+	if len(os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI")) > 0 || len(os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")) > 0 {
+		return credentials.NewCredentials(defaults.RemoteCredProvider(*tmpConfig, tmpHandlers))
+	}
+
+	sess, err := session.NewSession(tmpConfig)
+	if err != nil {
+		return credentials.NewCredentials(&errorProvider{err: err})
+	}
+	meta := ec2metadata.New(sess, aws.NewConfig().WithEC2MetadataEnableFallback(false))
+	return credentials.NewCredentials(&ec2rolecreds.EC2RoleProvider{
+		Client: meta,
+	})
+}
+
+// AssumeRoleProvider assumes RoleARN using the credentials resolved from
+// Base as the calling identity, prompting for an MFA token code on stdin
+// when MFASerial is set.
+type AssumeRoleProvider struct {
+	Base CredentialProvider
+
+	Region      string
+	RoleARN     string
+	ExternalID  string
+	MFASerial   string
+	SessionName string
+}
+
+// Credentials implements CredentialProvider.
+func (p *AssumeRoleProvider) Credentials() *credentials.Credentials {
+	sess, err := session.NewSession(&aws.Config{
+		Credentials: p.Base.Credentials(),
+		Region:      aws.String(p.Region),
+	})
+	if err != nil {
+		return credentials.NewCredentials(&errorProvider{err: err})
+	}
+
+	sessionName := p.SessionName
+	if len(sessionName) == 0 {
+		sessionName = defaultAssumeRoleSessionName
+	}
+
+	return stscreds.NewCredentials(sess, p.RoleARN, func(aro *stscreds.AssumeRoleProvider) {
+		aro.RoleSessionName = sessionName
+		if len(p.ExternalID) > 0 {
+			aro.ExternalID = aws.String(p.ExternalID)
+		}
+		if len(p.MFASerial) > 0 {
+			aro.SerialNumber = aws.String(p.MFASerial)
+			aro.TokenProvider = stscreds.StdinTokenProvider
+		}
+	})
+}
+
+// newConfig returns a new aws config built from opts.
+func newConfig(opts CredentialOptions) *aws.Config {
+	defaultConfig := defaults.Get().Config
+	region := getenv(opts.Region, "AWS_DEFAULT_REGION")
+	return defaultConfig.WithCredentials(newCredentials(opts)).WithRegion(region)
+}
+
+// newCredentials chains the shared profile, environment variable and
+// EC2/ECS remote providers, reading the profile before environment
+// variables, and wraps the result in AssumeRoleProvider when opts.AssumeRoleARN is set.
+func newCredentials(opts CredentialOptions) *credentials.Credentials {
+	profile := getenv(opts.Profile, "AWS_DEFAULT_PROFILE")
+	region := getenv(opts.Region, "AWS_DEFAULT_REGION")
+
+	base := credentials.NewChainCredentials([]credentials.Provider{
+		&credentialsProviderAdapter{CredentialProvider: &SharedProfileProvider{Profile: profile, Region: region}},
+		&credentialsProviderAdapter{CredentialProvider: &EnvProvider{}},
+		&credentialsProviderAdapter{CredentialProvider: &RemoteProvider{Region: region}},
+	})
+
+	if len(opts.AssumeRoleARN) == 0 {
+		return base
+	}
+
+	assumeRole := &AssumeRoleProvider{
+		Base:        (*credentialsProvider)(base),
+		Region:      region,
+		RoleARN:     opts.AssumeRoleARN,
+		ExternalID:  opts.ExternalID,
+		MFASerial:   opts.MFASerial,
+		SessionName: opts.SessionName,
+	}
+	return assumeRole.Credentials()
+}
+
+// credentialsProvider adapts an already-resolved *credentials.Credentials to
+// the CredentialProvider interface so it can be used as AssumeRoleProvider.Base.
+type credentialsProvider credentials.Credentials
+
+func (c *credentialsProvider) Credentials() *credentials.Credentials {
+	return (*credentials.Credentials)(c)
+}
+
+// errorProvider is a credentials.Provider that always fails with err. It lets
+// a CredentialProvider defer a session construction failure (e.g. an unknown
+// --profile) to the point the chain actually tries to use it, rather than
+// panicking eagerly via session.Must and taking down the whole process before
+// the chain gets a chance to fall through to the next provider.
+type errorProvider struct {
+	err error
+}
+
+func (e *errorProvider) Retrieve() (credentials.Value, error) {
+	return credentials.Value{}, e.err
+}
+
+func (e *errorProvider) IsExpired() bool {
+	return true
+}
+
+// credentialsProviderAdapter adapts a CredentialProvider to the
+// credentials.Provider interface expected by credentials.NewChainCredentials.
+// It resolves the underlying *credentials.Credentials once and reuses it,
+// rather than calling CredentialProvider.Credentials() (which may build a new
+// session, re-read the shared config file, or re-do an IMDSv2 token fetch)
+// on every Retrieve/IsExpired call the chain makes.
+type credentialsProviderAdapter struct {
+	CredentialProvider
+
+	once  sync.Once
+	creds *credentials.Credentials
+}
+
+func (a *credentialsProviderAdapter) resolve() *credentials.Credentials {
+	a.once.Do(func() {
+		a.creds = a.CredentialProvider.Credentials()
+	})
+	return a.creds
+}
+
+func (a *credentialsProviderAdapter) Retrieve() (credentials.Value, error) {
+	return a.resolve().Get()
+}
+
+func (a *credentialsProviderAdapter) IsExpired() bool {
+	return a.resolve().IsExpired()
 }
 
 func getenv(value, key string) string {
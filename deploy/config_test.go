@@ -0,0 +1,76 @@
+package deploy
+
+import (
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/pkg/errors"
+)
+
+func TestGetenv(t *testing.T) {
+	const key = "ECS_GOPLOY_TEST_GETENV"
+	os.Setenv(key, "from-env")
+	defer os.Unsetenv(key)
+
+	cases := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{name: "explicit value wins", value: "explicit", want: "explicit"},
+		{name: "empty value falls back to env", value: "", want: "from-env"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := getenv(c.value, key); got != c.want {
+				t.Errorf("getenv(%q, %q) = %q, want %q", c.value, key, got, c.want)
+			}
+		})
+	}
+}
+
+func TestErrorProvider(t *testing.T) {
+	wantErr := errors.New("boom")
+	p := &errorProvider{err: wantErr}
+
+	if !p.IsExpired() {
+		t.Error("IsExpired() = false, want true")
+	}
+	_, err := p.Retrieve()
+	if errors.Cause(err) != wantErr {
+		t.Errorf("Retrieve() error = %v, want %v", err, wantErr)
+	}
+}
+
+// countingProvider is a CredentialProvider that counts how many times
+// Credentials is called, so tests can assert credentialsProviderAdapter
+// only resolves it once.
+type countingProvider struct {
+	calls int
+	creds *credentials.Credentials
+}
+
+func (c *countingProvider) Credentials() *credentials.Credentials {
+	c.calls++
+	return c.creds
+}
+
+func TestCredentialsProviderAdapterResolvesOnce(t *testing.T) {
+	inner := &countingProvider{creds: credentials.NewStaticCredentials("id", "secret", "")}
+	adapter := &credentialsProviderAdapter{CredentialProvider: inner}
+
+	if _, err := adapter.Retrieve(); err != nil {
+		t.Fatalf("Retrieve() error: %v", err)
+	}
+	if adapter.IsExpired() {
+		t.Error("IsExpired() = true after Retrieve, want false for static credentials")
+	}
+	if _, err := adapter.Retrieve(); err != nil {
+		t.Fatalf("second Retrieve() error: %v", err)
+	}
+
+	if inner.calls != 1 {
+		t.Errorf("underlying CredentialProvider.Credentials() called %d times, want 1", inner.calls)
+	}
+}
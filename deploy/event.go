@@ -0,0 +1,63 @@
+package deploy
+
+import "time"
+
+// EventPhase identifies the stage of a rollout or task run an Event was
+// emitted for.
+type EventPhase string
+
+const (
+	// PhaseRegisteringTaskDef is emitted while a new task definition revision is being registered.
+	PhaseRegisteringTaskDef EventPhase = "RegisteringTaskDef"
+
+	// PhaseUpdatingService is emitted while a service is being updated or created.
+	PhaseUpdatingService EventPhase = "UpdatingService"
+
+	// PhaseWaitingSteadyState is emitted while waiting for a service to reach steady state.
+	PhaseWaitingSteadyState EventPhase = "WaitingSteadyState"
+
+	// PhaseTaskStarted is emitted once a one-off task has been launched.
+	PhaseTaskStarted EventPhase = "TaskStarted"
+
+	// PhaseTaskStopped is emitted once a one-off task has stopped, successfully or not.
+	PhaseTaskStopped EventPhase = "TaskStopped"
+
+	// PhaseRollbackTriggered is emitted when a rollout is being rolled back or torn down.
+	PhaseRollbackTriggered EventPhase = "RollbackTriggered"
+)
+
+// Event is a single structured progress update emitted by Task, CanaryDeploy
+// and Recreate on their Events channel, in place of a log.Printf call. Its
+// shape is designed to be serialized as NDJSON for CI pipelines to parse.
+type Event struct {
+	Phase     EventPhase
+	Message   string
+	TaskARN   *string
+	Timestamp time.Time
+	ExitCode  *int64
+}
+
+// eventsBufferSize is large enough to hold a full rollout or task run's
+// worth of events so publish never blocks on a consumer that only drains
+// once the call it is watching has returned.
+const eventsBufferSize = 32
+
+// newEvents returns a buffered Events channel.
+func newEvents() chan Event {
+	return make(chan Event, eventsBufferSize)
+}
+
+// publish sends an Event on events, stamping its Timestamp. It is a no-op if
+// events is nil, which keeps the zero value of Task/CanaryDeploy/Recreate usable.
+func publish(events chan Event, phase EventPhase, message string, taskARN *string, exitCode *int64) {
+	if events == nil {
+		return
+	}
+	events <- Event{
+		Phase:     phase,
+		Message:   message,
+		TaskARN:   taskARN,
+		Timestamp: time.Now(),
+		ExitCode:  exitCode,
+	}
+}
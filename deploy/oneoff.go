@@ -0,0 +1,183 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/pkg/errors"
+)
+
+// OneOffResult is the outcome of a single one-off task started by RunTasks.
+type OneOffResult struct {
+	// Name is the container name override of the --one-off entry this
+	// result came from.
+	Name string
+
+	// TaskARN is the ARN of the ECS task that was run.
+	TaskARN string
+
+	// ExitCode is the container's exit code. Only meaningful when Err is nil.
+	ExitCode int64
+
+	// Duration is how long the task took to reach STOPPED.
+	Duration time.Duration
+
+	// Err is set if the task could not be started or did not exit successfully.
+	Err error
+}
+
+// RunTasks runs multiple one-off Tasks against the same cluster, either
+// sequentially or concurrently, and returns one OneOffResult per task. When
+// run concurrently, every task is started before any of them are waited on,
+// and waitStoppedTasks multiplexes DescribeTasks calls across all of their
+// ARNs in shared batches rather than polling once per task.
+func RunTasks(tasks []*Task, parallel bool) ([]OneOffResult, error) {
+	if len(tasks) == 0 {
+		return nil, errors.New("no tasks to run")
+	}
+	if !parallel {
+		return runTasksSequential(tasks), nil
+	}
+	return runTasksConcurrent(tasks), nil
+}
+
+// AnyFailed reports whether any of the results represents a failed task, so
+// callers can derive a single process exit status from a batch of one-off tasks.
+func AnyFailed(results []OneOffResult) bool {
+	for _, r := range results {
+		if r.Err != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func runTasksSequential(tasks []*Task) []OneOffResult {
+	results := make([]OneOffResult, 0, len(tasks))
+	for _, t := range tasks {
+		results = append(results, runOne(t))
+	}
+	return results
+}
+
+func runOne(t *Task) OneOffResult {
+	defer closeEvents(t)
+	startedAt := time.Now()
+
+	taskDefinition, err := t.TaskDefinition.Describe(*t.BaseTaskDefinition)
+	if err != nil {
+		return OneOffResult{Name: t.Name, Err: err}
+	}
+
+	ecsTasks, err := t.RunTask(taskDefinition)
+	result := OneOffResult{Name: t.Name, Duration: time.Since(startedAt)}
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if len(ecsTasks) == 0 {
+		result.Err = errors.New("no task started")
+		return result
+	}
+
+	result.TaskARN = *ecsTasks[0].TaskArn
+	code, succeeded, err := t.checkTaskSucceeded(ecsTasks[0])
+	result.ExitCode = code
+	if err != nil {
+		result.Err = err
+	} else if !succeeded {
+		result.Err = errors.Errorf("exit code: %v", code)
+	}
+	return result
+}
+
+// runTasksConcurrent starts every task before waiting on any of them, then
+// waits for all of the resulting ECS tasks together so DescribeTasks calls
+// are shared across tasks instead of one poll loop per task.
+func runTasksConcurrent(tasks []*Task) []OneOffResult {
+	type run struct {
+		task      *Task
+		ecsTasks  []*ecs.Task
+		startedAt time.Time
+		startErr  error
+	}
+
+	runs := make([]run, len(tasks))
+	for i, t := range tasks {
+		ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
+		defer cancel()
+
+		taskDefinition, err := t.TaskDefinition.Describe(*t.BaseTaskDefinition)
+		if err != nil {
+			runs[i] = run{task: t, startErr: err}
+			continue
+		}
+		ecsTasks, err := t.startTask(ctx, taskDefinition)
+		runs[i] = run{task: t, ecsTasks: ecsTasks, startedAt: time.Now(), startErr: err}
+	}
+
+	var allArns []*string
+	for _, r := range runs {
+		for _, et := range r.ecsTasks {
+			allArns = append(allArns, et.TaskArn)
+		}
+	}
+
+	var stopped map[string]*ecs.Task
+	var waitErr error
+	if len(allArns) > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), tasks[0].Timeout)
+		defer cancel()
+		stopped, waitErr = waitStoppedTasks(ctx, NewWaiter(tasks[0].Poll), tasks[0].awsECS, tasks[0].Cluster, allArns)
+	}
+
+	results := make([]OneOffResult, 0, len(tasks))
+	for _, r := range runs {
+		if r.startErr != nil {
+			results = append(results, OneOffResult{Name: r.task.Name, Err: r.startErr})
+			closeEvents(r.task)
+			continue
+		}
+		if len(r.ecsTasks) == 0 {
+			results = append(results, OneOffResult{Name: r.task.Name, Err: errors.New("no task started")})
+			closeEvents(r.task)
+			continue
+		}
+		for _, et := range r.ecsTasks {
+			result := OneOffResult{Name: r.task.Name, TaskARN: *et.TaskArn, Duration: time.Since(r.startedAt)}
+			if waitErr != nil {
+				result.Err = waitErr
+				results = append(results, result)
+				continue
+			}
+			task, ok := stopped[*et.TaskArn]
+			if !ok {
+				result.Err = errors.Errorf("task %s did not stop before timeout", *et.TaskArn)
+				results = append(results, result)
+				continue
+			}
+			code, succeeded, err := r.task.checkTaskSucceeded(task)
+			result.ExitCode = code
+			publish(r.task.Events, PhaseTaskStopped, fmt.Sprintf("Task %s stopped", *et.TaskArn), et.TaskArn, aws.Int64(code))
+			if err != nil {
+				result.Err = err
+			} else if !succeeded {
+				result.Err = errors.Errorf("exit code: %v", code)
+			}
+			results = append(results, result)
+		}
+		closeEvents(r.task)
+	}
+	return results
+}
+
+// closeEvents closes t.Events, the last step of a Task's lifecycle when it
+// was run through RunTasks rather than RunTask directly.
+func closeEvents(t *Task) {
+	if t.Events != nil {
+		close(t.Events)
+	}
+}
@@ -0,0 +1,386 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/pkg/errors"
+)
+
+// recreateServiceSuffix names the temporary service created while a
+// recreate rollout is in progress.
+const recreateServiceSuffix = "-recreate"
+
+// CapacityProviderStrategyItem is a single entry in a capacity provider
+// strategy override, mirroring ecs.CapacityProviderStrategyItem.
+type CapacityProviderStrategyItem struct {
+	CapacityProvider string
+	Weight           int64
+	Base             int64
+}
+
+// RecreateOptions overrides the settings the temporary service is created
+// with, in place of carrying the original service's settings over verbatim.
+// Each field left at its zero value keeps the original service's setting,
+// so a recreate that doesn't need to change a given setting doesn't need to
+// specify it.
+type RecreateOptions struct {
+	// LaunchType overrides the original service's launch type, e.g. to
+	// switch EC2<->FARGATE.
+	LaunchType string
+
+	// Subnets and SecurityGroups override the original service's awsvpc
+	// network configuration. Both empty keep the original's network
+	// configuration.
+	Subnets        []string
+	SecurityGroups []string
+
+	// AssignPublicIP controls public IP assignment for the network
+	// configuration built from Subnets/SecurityGroups. Ignored unless
+	// Subnets or SecurityGroups is set.
+	AssignPublicIP bool
+
+	// ServiceRegistryARN overrides the original service's Cloud
+	// Map/ServiceConnect registry.
+	ServiceRegistryARN string
+
+	// CapacityProviderStrategy overrides the original service's capacity
+	// provider strategy. Nil keeps the original's.
+	CapacityProviderStrategy []CapacityProviderStrategyItem
+}
+
+// Recreate replaces a service with one running a new task definition that
+// cannot be applied in place with UpdateService - a launch type switch
+// (EC2<->FARGATE), a network mode change, a service-registry/ServiceConnect
+// change, or a capacity provider strategy change. Unlike Deploy, it creates
+// a temporary parallel service rather than updating the existing one.
+type Recreate struct {
+	awsECS ecsiface.ECSAPI
+
+	// Cluster is the name of the ECS cluster.
+	Cluster string
+
+	// Name is the name of the service to recreate.
+	Name string
+
+	// TaskDefinition is the base task definition to register a new revision from.
+	TaskDefinition *TaskDefinition
+
+	// NewImage is the image to deploy.
+	NewImage *Image
+
+	// Options overrides the settings carried over from the original
+	// service when creating the temporary one.
+	Options RecreateOptions
+
+	// Timeout bounds how long to wait for the temporary service to reach
+	// steady state before giving up and tearing it down. The rename step
+	// gets its own fresh Timeout budget rather than sharing this one, since
+	// by the time it runs the original wait may already have spent most of it.
+	Timeout time.Duration
+
+	// Events carries structured progress updates in place of log.Printf
+	// calls. It is closed once Recreate returns.
+	Events chan Event
+
+	// Poll controls the backoff used while waiting for the temporary
+	// service to reach steady state.
+	Poll WaiterOptions
+}
+
+// NewRecreate returns a new Recreate struct, and initializes the aws ecs API client.
+func NewRecreate(cluster, name, imageWithTag string, creds CredentialOptions, timeout time.Duration, opts RecreateOptions, poll WaiterOptions) (*Recreate, error) {
+	if len(opts.LaunchType) > 0 {
+		switch opts.LaunchType {
+		case ecs.LaunchTypeEc2, ecs.LaunchTypeFargate, ecs.LaunchTypeExternal:
+		default:
+			return nil, errors.Errorf("unsupported launch type: %s", opts.LaunchType)
+		}
+		if len(opts.CapacityProviderStrategy) > 0 {
+			return nil, errors.New("--launch-type and --capacity-provider-strategy are mutually exclusive")
+		}
+	}
+	if len(opts.SecurityGroups) > 0 && len(opts.Subnets) == 0 {
+		return nil, errors.New("--security-group requires --subnet to build a valid network configuration")
+	}
+
+	awsECS := ecs.New(session.New(), newConfig(creds))
+	taskDefinition := NewTaskDefinition(creds.Profile, creds.Region)
+
+	repository, tag, err := divideImageAndTag(imageWithTag)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Recreate{
+		awsECS:         awsECS,
+		Cluster:        cluster,
+		Name:           name,
+		TaskDefinition: taskDefinition,
+		NewImage: &Image{
+			*repository,
+			*tag,
+		},
+		Options: opts,
+		Timeout: timeout,
+		Events:  newEvents(),
+		Poll:    poll,
+	}, nil
+}
+
+// Recreate snapshots the existing service definition, registers the new
+// task definition, creates a temporary parallel service attached to the
+// same load balancer target group, waits for it to reach steady state, then
+// drains and deletes the old service. Before the original is touched, any
+// failure tears the temporary service back down and leaves the original
+// untouched. Once the temporary service has proven stable and the original
+// has started draining, it is the good copy - a failure past that point is
+// reported without tearing it down, so a transient DeleteService/rename
+// failure doesn't throw away the only healthy service left.
+func (r *Recreate) Recreate() error {
+	if r.Events != nil {
+		defer close(r.Events)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer cancel()
+
+	original, err := r.describeService(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to describe current service")
+	}
+
+	publish(r.Events, PhaseRegisteringTaskDef, "Registering new task definition", nil, nil)
+	newTaskDef, err := r.TaskDefinition.Register(r.Name, r.NewImage)
+	if err != nil {
+		return errors.Wrap(err, "failed to register new task definition")
+	}
+
+	tempName := r.Name + recreateServiceSuffix
+	publish(r.Events, PhaseUpdatingService, fmt.Sprintf("Creating temporary service %s", tempName), nil, nil)
+	if err := r.createTempService(ctx, original, tempName, newTaskDef); err != nil {
+		return errors.Wrap(err, "failed to create temporary service")
+	}
+
+	publish(r.Events, PhaseWaitingSteadyState, fmt.Sprintf("Waiting for %s to reach steady state", tempName), nil, nil)
+	if err := r.waitServiceStable(ctx, tempName); err != nil {
+		publish(r.Events, PhaseRollbackTriggered, fmt.Sprintf("Temporary service %s failed to stabilize, tearing it down", tempName), nil, nil)
+		r.teardown(tempName)
+		return errors.Wrap(err, "temporary service failed to reach steady state")
+	}
+
+	publish(r.Events, PhaseUpdatingService, fmt.Sprintf("Draining original service %s", r.Name), nil, nil)
+	if err := r.drainAndDelete(original); err != nil {
+		return errors.Wrapf(err, "failed to drain original service, temporary service %s left running", tempName)
+	}
+
+	publish(r.Events, PhaseUpdatingService, fmt.Sprintf("Renaming %s to %s", tempName, r.Name), nil, nil)
+	renameCtx, renameCancel := context.WithTimeout(context.Background(), r.Timeout)
+	defer renameCancel()
+	if err := r.rename(renameCtx, tempName); err != nil {
+		return errors.Wrap(err, "failed to rename temporary service")
+	}
+
+	return nil
+}
+
+// describeService fetches the current state of the service to be recreated.
+func (r *Recreate) describeService(ctx context.Context) (*ecs.Service, error) {
+	resp, err := r.awsECS.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(r.Cluster),
+		Services: []*string{aws.String(r.Name)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Services) == 0 {
+		return nil, errors.Errorf("service %s not found", r.Name)
+	}
+	return resp.Services[0], nil
+}
+
+// createTempService creates the temporary service running the new task
+// definition, carrying over the original service's launch type, network
+// configuration, load balancer attachments, service registries and
+// capacity provider strategy except where r.Options overrides them.
+func (r *Recreate) createTempService(ctx context.Context, original *ecs.Service, tempName string, taskDef *ecs.TaskDefinition) error {
+	_, err := r.awsECS.CreateServiceWithContext(ctx, &ecs.CreateServiceInput{
+		Cluster:                  aws.String(r.Cluster),
+		ServiceName:              aws.String(tempName),
+		TaskDefinition:           taskDef.TaskDefinitionArn,
+		DesiredCount:             original.DesiredCount,
+		LaunchType:               r.launchType(original),
+		NetworkConfiguration:     r.networkConfiguration(original),
+		LoadBalancers:            original.LoadBalancers,
+		ServiceRegistries:        r.serviceRegistries(original),
+		CapacityProviderStrategy: r.capacityProviderStrategy(original),
+	})
+	return err
+}
+
+// launchType resolves the launch type to create the temporary service with,
+// preferring r.Options.LaunchType over the original service's. It returns
+// nil whenever r.Options.CapacityProviderStrategy is set, even if the
+// original service had a launch type, since ECS's CreateService rejects a
+// request that sets both.
+func (r *Recreate) launchType(original *ecs.Service) *string {
+	if len(r.Options.CapacityProviderStrategy) > 0 {
+		return nil
+	}
+	if len(r.Options.LaunchType) > 0 {
+		return aws.String(r.Options.LaunchType)
+	}
+	return original.LaunchType
+}
+
+// networkConfiguration resolves the network configuration to create the
+// temporary service with. r.Options overrides the original service's
+// network configuration as soon as either Subnets or SecurityGroups is set.
+func (r *Recreate) networkConfiguration(original *ecs.Service) *ecs.NetworkConfiguration {
+	if len(r.Options.Subnets) == 0 && len(r.Options.SecurityGroups) == 0 {
+		return original.NetworkConfiguration
+	}
+	assignPublicIP := ecs.AssignPublicIpDisabled
+	if r.Options.AssignPublicIP {
+		assignPublicIP = ecs.AssignPublicIpEnabled
+	}
+	return &ecs.NetworkConfiguration{
+		AwsvpcConfiguration: &ecs.AwsVpcConfiguration{
+			Subnets:        aws.StringSlice(r.Options.Subnets),
+			SecurityGroups: aws.StringSlice(r.Options.SecurityGroups),
+			AssignPublicIp: aws.String(assignPublicIP),
+		},
+	}
+}
+
+// serviceRegistries resolves the service registries to create the temporary
+// service with, preferring r.Options.ServiceRegistryARN over the original
+// service's registries. The new registry keeps the original's
+// ContainerName/ContainerPort when it had exactly one registry, since ECS
+// requires those fields for anything but a single-container awsvpc task.
+func (r *Recreate) serviceRegistries(original *ecs.Service) []*ecs.ServiceRegistry {
+	if len(r.Options.ServiceRegistryARN) == 0 {
+		return original.ServiceRegistries
+	}
+	registry := &ecs.ServiceRegistry{RegistryArn: aws.String(r.Options.ServiceRegistryARN)}
+	if len(original.ServiceRegistries) == 1 {
+		registry.ContainerName = original.ServiceRegistries[0].ContainerName
+		registry.ContainerPort = original.ServiceRegistries[0].ContainerPort
+	}
+	return []*ecs.ServiceRegistry{registry}
+}
+
+// capacityProviderStrategy resolves the capacity provider strategy to create
+// the temporary service with, preferring r.Options.CapacityProviderStrategy
+// over the original service's strategy. It returns nil whenever
+// r.Options.LaunchType is set, even if the original service had a capacity
+// provider strategy, since ECS's CreateService rejects a request that sets
+// both.
+func (r *Recreate) capacityProviderStrategy(original *ecs.Service) []*ecs.CapacityProviderStrategyItem {
+	if len(r.Options.LaunchType) > 0 {
+		return nil
+	}
+	if len(r.Options.CapacityProviderStrategy) == 0 {
+		return original.CapacityProviderStrategy
+	}
+	items := make([]*ecs.CapacityProviderStrategyItem, 0, len(r.Options.CapacityProviderStrategy))
+	for _, item := range r.Options.CapacityProviderStrategy {
+		items = append(items, &ecs.CapacityProviderStrategyItem{
+			CapacityProvider: aws.String(item.CapacityProvider),
+			Weight:           aws.Int64(item.Weight),
+			Base:             aws.Int64(item.Base),
+		})
+	}
+	return items
+}
+
+// waitServiceStable polls until the named service reaches steady state. See
+// the package-level waitServiceStable for the shared implementation.
+func (r *Recreate) waitServiceStable(ctx context.Context, name string) error {
+	return waitServiceStable(ctx, NewWaiter(r.Poll), r.awsECS, r.Cluster, name)
+}
+
+// drainAndDelete scales the original service to zero and deletes it.
+func (r *Recreate) drainAndDelete(original *ecs.Service) error {
+	_, err := r.awsECS.UpdateService(&ecs.UpdateServiceInput{
+		Cluster:      aws.String(r.Cluster),
+		Service:      original.ServiceName,
+		DesiredCount: aws.Int64(0),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = r.awsECS.DeleteService(&ecs.DeleteServiceInput{
+		Cluster: aws.String(r.Cluster),
+		Service: original.ServiceName,
+	})
+	return err
+}
+
+// rename re-registers the temporary service under the original service's
+// name by creating a service of that name pointed at the same task
+// definition. ECS has no native rename and service names must be unique per
+// cluster, so by this point the original has already been deleted and there
+// is an unavoidable window where no service carries the original name -
+// this is as small as ECS's API allows it to be. When the temporary service
+// carries a service registry (Cloud Map/ServiceConnect), that window also
+// has to cover the registry: a registry can only be associated with one
+// service at a time, so the temporary service must be drained and deleted
+// before the final-named one can be created with it, rather than torn down
+// afterward as with the no-registry case. ctx is expected to carry its own
+// fresh timeout budget rather than one a prior wait has already spent, so
+// that a slow rename isn't cut short by time spent validating the temporary
+// service.
+func (r *Recreate) rename(ctx context.Context, tempName string) error {
+	resp, err := r.awsECS.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(r.Cluster),
+		Services: []*string{aws.String(tempName)},
+	})
+	if err != nil {
+		return err
+	}
+	if len(resp.Services) == 0 {
+		return errors.Errorf("temporary service %s disappeared before rename", tempName)
+	}
+	temp := resp.Services[0]
+
+	if len(temp.ServiceRegistries) > 0 {
+		if err := r.drainAndDelete(temp); err != nil {
+			return errors.Wrap(err, "failed to drain temporary service ahead of registry handoff")
+		}
+	}
+
+	_, err = r.awsECS.CreateServiceWithContext(ctx, &ecs.CreateServiceInput{
+		Cluster:                  aws.String(r.Cluster),
+		ServiceName:              aws.String(r.Name),
+		TaskDefinition:           temp.TaskDefinition,
+		DesiredCount:             temp.DesiredCount,
+		LaunchType:               temp.LaunchType,
+		NetworkConfiguration:     temp.NetworkConfiguration,
+		LoadBalancers:            temp.LoadBalancers,
+		ServiceRegistries:        temp.ServiceRegistries,
+		CapacityProviderStrategy: temp.CapacityProviderStrategy,
+	})
+	if err != nil {
+		return err
+	}
+	if err := r.waitServiceStable(ctx, r.Name); err != nil {
+		return err
+	}
+
+	if len(temp.ServiceRegistries) == 0 {
+		r.teardown(tempName)
+	}
+	return nil
+}
+
+// teardown scales down and removes the temporary service. See the
+// package-level teardownService for the shared implementation.
+func (r *Recreate) teardown(tempName string) {
+	teardownService(r.awsECS, r.Cluster, tempName)
+}
@@ -0,0 +1,65 @@
+package deploy
+
+import (
+	"context"
+	"log"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ecs"
+	"github.com/aws/aws-sdk-go/service/ecs/ecsiface"
+	"github.com/pkg/errors"
+)
+
+// waitServiceStable polls until the named service reaches steady state,
+// backing off exponentially between rounds and absorbing API throttling via
+// waiter instead of the AWS SDK's fixed-interval service waiter. Shared by
+// CanaryDeploy and Recreate, which both stand up a temporary service and
+// wait for it the same way.
+func waitServiceStable(ctx context.Context, waiter *Waiter, awsECS ecsiface.ECSAPI, cluster, name string) error {
+	return waiter.Wait(ctx, func() (bool, error) {
+		return describeServiceStable(ctx, awsECS, cluster, name)
+	})
+}
+
+// describeServiceStable reports whether the named service has a single
+// active deployment running its desired count of tasks.
+func describeServiceStable(ctx context.Context, awsECS ecsiface.ECSAPI, cluster, name string) (bool, error) {
+	resp, err := awsECS.DescribeServicesWithContext(ctx, &ecs.DescribeServicesInput{
+		Cluster:  aws.String(cluster),
+		Services: []*string{aws.String(name)},
+	})
+	if err != nil {
+		return false, err
+	}
+	if len(resp.Services) == 0 {
+		return false, errors.Errorf("service %s not found", name)
+	}
+	svc := resp.Services[0]
+	if svc.RunningCount == nil || svc.DesiredCount == nil {
+		return false, nil
+	}
+	return len(svc.Deployments) == 1 && *svc.RunningCount == *svc.DesiredCount, nil
+}
+
+// teardownService scales down and removes the named temporary service. It
+// logs rather than returns errors since it runs on both the success and
+// rollback paths and should not mask the original outcome. Shared by
+// CanaryDeploy and Recreate, which both discard a temporary service once
+// it's no longer needed.
+func teardownService(awsECS ecsiface.ECSAPI, cluster, name string) {
+	_, err := awsECS.UpdateService(&ecs.UpdateServiceInput{
+		Cluster:      aws.String(cluster),
+		Service:      aws.String(name),
+		DesiredCount: aws.Int64(0),
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to scale down service %s: %v\n", name, err)
+	}
+	_, err = awsECS.DeleteService(&ecs.DeleteServiceInput{
+		Cluster: aws.String(cluster),
+		Service: aws.String(name),
+	})
+	if err != nil {
+		log.Printf("[ERROR] Failed to delete service %s: %v\n", name, err)
+	}
+}
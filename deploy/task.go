@@ -2,7 +2,7 @@ package deploy
 
 import (
 	"context"
-	"log"
+	"fmt"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -38,16 +38,24 @@ type Task struct {
 	// Wait time when run task.
 	// This script monitors ECS task for new task definition to be running after call run task API.
 	Timeout time.Duration
+
+	// Events carries structured progress updates in place of log.Printf
+	// calls. It is closed once the task has stopped. Consumers that don't
+	// want to read it (e.g. tests constructing Task by hand) can leave it nil.
+	Events chan Event
+
+	// Poll controls the backoff used while waiting for the task to stop.
+	Poll WaiterOptions
 }
 
 // NewTask returns a new Task struct, and initialize aws ecs API client.
 // Separates imageWithTag into repository and tag, then set NewImage for deploy.
-func NewTask(cluster, name, imageWithTag, command string, baseTaskDefinition *string, timeout time.Duration, profile, region string) (*Task, error) {
+func NewTask(cluster, name, imageWithTag, command string, baseTaskDefinition *string, timeout time.Duration, creds CredentialOptions, poll WaiterOptions) (*Task, error) {
 	if baseTaskDefinition == nil {
 		return nil, errors.New("task definition is required")
 	}
-	awsECS := ecs.New(session.New(), newConfig(profile, region))
-	taskDefinition := NewTaskDefinition(profile, region)
+	awsECS := ecs.New(session.New(), newConfig(creds))
+	taskDefinition := NewTaskDefinition(creds.Profile, creds.Region)
 	var newImage *Image
 	if len(imageWithTag) > 0 {
 		var err error
@@ -79,14 +87,33 @@ func NewTask(cluster, name, imageWithTag, command string, baseTaskDefinition *st
 		NewImage:           newImage,
 		Command:            cmd,
 		Timeout:            timeout,
+		Events:             newEvents(),
+		Poll:               poll,
 	}, nil
 }
 
-// RunTask calls run-task API.
+// RunTask calls run-task API and returns the tasks' final, post-stop state,
+// exit codes included.
 func (t *Task) RunTask(taskDefinition *ecs.TaskDefinition) ([]*ecs.Task, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), t.Timeout)
 	defer cancel()
 
+	tasks, err := t.startTask(ctx, taskDefinition)
+	if err != nil {
+		return nil, err
+	}
+
+	stopped, err := t.waitRunning(ctx, tasks)
+	if err != nil {
+		return tasks, err
+	}
+	return stopped, nil
+}
+
+// startTask calls the run-task API and returns the started tasks without
+// waiting for them to stop. It is split out from RunTask so RunTasks can
+// start several one-off tasks before waiting on all of them together.
+func (t *Task) startTask(ctx context.Context, taskDefinition *ecs.TaskDefinition) ([]*ecs.Task, error) {
 	containerOverride := &ecs.ContainerOverride{
 		Command: t.Command,
 		Name:    aws.String(t.Name),
@@ -108,87 +135,117 @@ func (t *Task) RunTask(taskDefinition *ecs.TaskDefinition) ([]*ecs.Task, error)
 		return nil, err
 	}
 	if len(resp.Failures) > 0 {
-		log.Printf("[ERROR] Run task error: %+v\n", resp.Failures)
 		return nil, errors.New(*resp.Failures[0].Reason)
 	}
-	log.Printf("[INFO] Running tasks: %+v\n", resp.Tasks)
-
-	err = t.waitRunning(ctx, resp.Tasks)
-	if err != nil {
-		return resp.Tasks, err
+	for _, task := range resp.Tasks {
+		publish(t.Events, PhaseTaskStarted, fmt.Sprintf("Running task %s", *task.TaskArn), task.TaskArn, nil)
 	}
+
 	return resp.Tasks, nil
 }
 
-// waitRunning waits a task running.
-func (t *Task) waitRunning(ctx context.Context, tasks []*ecs.Task) error {
-	log.Println("[INFO] Waiting for running task...")
+// waitRunning waits for tasks to stop and returns their final state, in the
+// same order as tasks.
+func (t *Task) waitRunning(ctx context.Context, tasks []*ecs.Task) ([]*ecs.Task, error) {
+	publish(t.Events, PhaseWaitingSteadyState, "Waiting for running task...", nil, nil)
 
 	taskArns := []*string{}
 	for _, task := range tasks {
 		taskArns = append(taskArns, task.TaskArn)
 	}
-	errCh := make(chan error, 1)
-	done := make(chan struct{}, 1)
+
+	type outcome struct {
+		stopped map[string]*ecs.Task
+		err     error
+	}
+	outCh := make(chan outcome, 1)
 	go func() {
-		err := t.waitExitTasks(taskArns)
-		if err != nil {
-			errCh <- err
-		}
-		close(done)
+		stopped, err := t.waitExitTasks(ctx, taskArns)
+		outCh <- outcome{stopped, err}
 	}()
 	select {
-	case err := <-errCh:
-		if err != nil {
-			return err
+	case out := <-outCh:
+		if out.err != nil {
+			return nil, out.err
 		}
-	case <-done:
-		log.Println("[INFO] Run task is success")
+		result := make([]*ecs.Task, 0, len(taskArns))
+		for _, arn := range taskArns {
+			result = append(result, out.stopped[*arn])
+		}
+		return result, nil
 	case <-ctx.Done():
-		return errors.New("process timeout")
+		return nil, errors.New("process timeout")
 	}
-
-	return nil
 }
 
-func (t *Task) waitExitTasks(taskArns []*string) error {
-retry:
-	for {
-		time.Sleep(5 * time.Second)
-
-		params := &ecs.DescribeTasksInput{
-			Cluster: aws.String(t.Cluster),
-			Tasks:   taskArns,
-		}
-		resp, err := t.awsECS.DescribeTasks(params)
+// waitExitTasks waits for taskArns to stop and returns their final,
+// post-stop state. It does not close t.Events - callers (runOne via
+// RunTask, or RunTasks directly) own that, since waitExitTasks isn't always
+// the last thing done with a Task (see the caller-owned close in
+// closeEvents).
+func (t *Task) waitExitTasks(ctx context.Context, taskArns []*string) (map[string]*ecs.Task, error) {
+	stopped, err := waitStoppedTasks(ctx, NewWaiter(t.Poll), t.awsECS, t.Cluster, taskArns)
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range stopped {
+		code, result, err := t.checkTaskSucceeded(task)
+		publish(t.Events, PhaseTaskStopped, fmt.Sprintf("Task %s stopped", *task.TaskArn), task.TaskArn, aws.Int64(code))
 		if err != nil {
-			return err
+			return stopped, err
 		}
-
-		for _, task := range resp.Tasks {
-			if !t.checkTaskStopped(task) {
-				continue retry
-			}
+		if !result {
+			return stopped, errors.Errorf("exit code: %v", code)
 		}
+	}
+	return stopped, nil
+}
+
+// describeTasksBatchSize is the maximum number of task ARNs the ECS
+// DescribeTasks API accepts in a single call.
+const describeTasksBatchSize = 100
 
-		for _, task := range resp.Tasks {
-			code, result, err := t.checkTaskSucceeded(task)
+// waitStoppedTasks polls DescribeTasks for the given task ARNs until every
+// one of them has stopped, splitting the ARNs into batches of at most
+// describeTasksBatchSize per call. This lets callers running many tasks at
+// once (see RunTasks) multiplex all of their polling through one shared set
+// of DescribeTasks calls instead of one goroutine per task. Polling backs
+// off exponentially between rounds via waiter, which also absorbs
+// DescribeTasks throttling instead of failing the whole batch.
+func waitStoppedTasks(ctx context.Context, waiter *Waiter, awsECS ecsiface.ECSAPI, cluster string, taskArns []*string) (map[string]*ecs.Task, error) {
+	pending := taskArns
+	stopped := make(map[string]*ecs.Task, len(taskArns))
+
+	err := waiter.Wait(ctx, func() (bool, error) {
+		var next []*string
+		for start := 0; start < len(pending); start += describeTasksBatchSize {
+			end := start + describeTasksBatchSize
+			if end > len(pending) {
+				end = len(pending)
+			}
+			resp, err := awsECS.DescribeTasksWithContext(ctx, &ecs.DescribeTasksInput{
+				Cluster: aws.String(cluster),
+				Tasks:   pending[start:end],
+			})
 			if err != nil {
-				continue retry
+				return false, err
 			}
-			if !result {
-				return errors.Errorf("exit code: %v", code)
+			for _, task := range resp.Tasks {
+				if *task.LastStatus != "STOPPED" {
+					next = append(next, task.TaskArn)
+					continue
+				}
+				stopped[*task.TaskArn] = task
 			}
 		}
-		return nil
+		pending = next
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (t *Task) checkTaskStopped(task *ecs.Task) bool {
-	if *task.DesiredStatus != "STOPPED" {
-		return false
-	}
-	return true
+	return stopped, nil
 }
 
 func (t *Task) checkTaskSucceeded(task *ecs.Task) (int64, bool, error) {
@@ -0,0 +1,123 @@
+package deploy
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+)
+
+// WaiterOptions configures a Waiter's polling cadence.
+type WaiterOptions struct {
+	// Initial is the delay before the first poll.
+	Initial time.Duration
+
+	// Max caps the delay between polls.
+	Max time.Duration
+
+	// Multiplier scales the delay after each poll that isn't done yet, and
+	// again on top of that when the poll was throttled.
+	Multiplier float64
+}
+
+// DefaultWaiterOptions matches the fixed 5 second interval the task and
+// service waiters used before they grew backoff and jitter.
+var DefaultWaiterOptions = WaiterOptions{
+	Initial:    5 * time.Second,
+	Max:        60 * time.Second,
+	Multiplier: 2,
+}
+
+// withDefaults fills any zero field of opts from DefaultWaiterOptions.
+func (opts WaiterOptions) withDefaults() WaiterOptions {
+	if opts.Initial <= 0 {
+		opts.Initial = DefaultWaiterOptions.Initial
+	}
+	if opts.Max <= 0 {
+		opts.Max = DefaultWaiterOptions.Max
+	}
+	if opts.Multiplier <= 0 {
+		opts.Multiplier = DefaultWaiterOptions.Multiplier
+	}
+	return opts
+}
+
+// Waiter polls a condition with exponential backoff and jitter, extending
+// its delay further whenever the condition reports AWS API throttling.
+// Running many pollers at once (several one-off tasks, or a canary bake)
+// shares this backoff so a burst of DescribeTasks/DescribeServices calls
+// doesn't trip ECS rate limits.
+type Waiter struct {
+	options WaiterOptions
+	delay   time.Duration
+}
+
+// NewWaiter returns a Waiter configured with opts, falling back to
+// DefaultWaiterOptions for any zero field.
+func NewWaiter(opts WaiterOptions) *Waiter {
+	opts = opts.withDefaults()
+	return &Waiter{options: opts, delay: opts.Initial}
+}
+
+// Wait polls fn, which reports whether the condition is met, until fn
+// returns true, ctx is done, or fn returns a non-throttling error. Each
+// round waits w's current delay first, then polls, mirroring the fixed
+// sleep-then-check loop this replaces.
+func (w *Waiter) Wait(ctx context.Context, fn func() (bool, error)) error {
+	for {
+		select {
+		case <-time.After(w.jitter()):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		done, err := fn()
+		if err != nil {
+			if !isThrottlingError(err) {
+				return err
+			}
+			w.growDelay(true)
+			continue
+		}
+		if done {
+			return nil
+		}
+		w.growDelay(false)
+	}
+}
+
+// growDelay advances the backoff, doubling it again on top of the normal
+// multiplier when the last poll was throttled.
+func (w *Waiter) growDelay(throttled bool) {
+	next := time.Duration(float64(w.delay) * w.options.Multiplier)
+	if throttled {
+		next = time.Duration(float64(next) * w.options.Multiplier)
+	}
+	if next > w.options.Max {
+		next = w.options.Max
+	}
+	w.delay = next
+}
+
+// jitter returns the current delay randomized by up to +/-20%, so many
+// concurrent Waiters don't all poll in lockstep.
+func (w *Waiter) jitter() time.Duration {
+	spread := float64(w.delay) * 0.2
+	return w.delay + time.Duration(rand.Float64()*2*spread-spread)
+}
+
+// isThrottlingError reports whether err is an AWS API throttling error
+// (ThrottlingException, RequestLimitExceeded, or the API-Gateway-style
+// TooManyRequestsException some SDK calls surface).
+func isThrottlingError(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case "ThrottlingException", "RequestLimitExceeded", "TooManyRequestsException":
+		return true
+	}
+	return false
+}
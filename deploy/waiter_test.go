@@ -0,0 +1,96 @@
+package deploy
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaiterOptionsWithDefaults(t *testing.T) {
+	cases := []struct {
+		name string
+		opts WaiterOptions
+		want WaiterOptions
+	}{
+		{
+			name: "all zero falls back to defaults",
+			opts: WaiterOptions{},
+			want: DefaultWaiterOptions,
+		},
+		{
+			name: "explicit fields are kept",
+			opts: WaiterOptions{Initial: 1, Max: 2, Multiplier: 3},
+			want: WaiterOptions{Initial: 1, Max: 2, Multiplier: 3},
+		},
+		{
+			name: "negative fields fall back to defaults",
+			opts: WaiterOptions{Initial: -1, Max: -1, Multiplier: -1},
+			want: DefaultWaiterOptions,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := c.opts.withDefaults()
+			if got != c.want {
+				t.Errorf("withDefaults() = %+v, want %+v", got, c.want)
+			}
+		})
+	}
+}
+
+func TestWaiterGrowDelay(t *testing.T) {
+	cases := []struct {
+		name      string
+		opts      WaiterOptions
+		delay     time.Duration
+		throttled bool
+		want      time.Duration
+	}{
+		{
+			name:  "doubles by the multiplier",
+			opts:  WaiterOptions{Initial: 1, Max: 100, Multiplier: 2},
+			delay: 10,
+			want:  20,
+		},
+		{
+			name:      "doubles again when throttled",
+			opts:      WaiterOptions{Initial: 1, Max: 100, Multiplier: 2},
+			delay:     10,
+			throttled: true,
+			want:      40,
+		},
+		{
+			name:  "caps at Max",
+			opts:  WaiterOptions{Initial: 1, Max: 15, Multiplier: 2},
+			delay: 10,
+			want:  15,
+		},
+		{
+			name:      "caps at Max even when throttled",
+			opts:      WaiterOptions{Initial: 1, Max: 15, Multiplier: 2},
+			delay:     10,
+			throttled: true,
+			want:      15,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := &Waiter{options: c.opts, delay: c.delay}
+			w.growDelay(c.throttled)
+			if w.delay != c.want {
+				t.Errorf("growDelay(%v) = %v, want %v", c.throttled, w.delay, c.want)
+			}
+		})
+	}
+}
+
+func TestWaiterJitterWithinBounds(t *testing.T) {
+	w := &Waiter{options: WaiterOptions{Initial: 10, Max: 100, Multiplier: 2}, delay: 10}
+	min := time.Duration(8)
+	max := time.Duration(12)
+	for i := 0; i < 100; i++ {
+		got := w.jitter()
+		if got < min || got > max {
+			t.Fatalf("jitter() = %v, want within [%v, %v]", got, min, max)
+		}
+	}
+}